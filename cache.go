@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheEntry is one row of the on-disk hash cache, keyed by absolute path.
+type cacheEntry struct {
+	Size    int64
+	ModTime int64 // UnixNano
+	Algo    string
+	Hash    string
+}
+
+// hashCache is an in-memory, gob-backed cache of file hashes keyed by
+// absolute path. A nil *hashCache is valid and simply disables caching.
+type hashCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+	refresh bool // -refresh-cache: ignore existing entries, but still keep and persist them
+}
+
+// defaultCachePath returns ~/.cache/md5-tool/cache.db (or the OS equivalent).
+func defaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "md5-tool", "cache.db"), nil
+}
+
+// loadHashCache reads the gob-encoded cache at path, or returns an empty one
+// if it doesn't exist yet.
+func loadHashCache(path string) (*hashCache, error) {
+	c := &hashCache{path: path, entries: make(map[string]cacheEntry)}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := gob.NewDecoder(f).Decode(&c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// hash returns the hash of path under algo, reusing a cached value when the
+// file's size and mtime haven't changed since it was last recorded. If
+// refresh is set (-refresh-cache), the existing entry is ignored and
+// recomputed, but other paths' entries are left untouched so save() doesn't
+// drop them. A nil receiver always falls through to a fresh getHash.
+func (c *hashCache) hash(path, algo string) (string, error) {
+	if c == nil {
+		return getHash(path, algo)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[abs]
+	c.mu.Unlock()
+	if !c.refresh && ok && entry.Algo == algo && entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano() {
+		return entry.Hash, nil
+	}
+
+	sum, err := getHash(abs, algo)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.entries[abs] = cacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Algo: algo, Hash: sum}
+	c.dirty = true
+	c.mu.Unlock()
+	return sum, nil
+}
+
+// save writes the cache back to disk if it was loaded from one and has
+// unsaved changes. A nil receiver is a no-op.
+func (c *hashCache) save() error {
+	if c == nil || !c.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	err = gob.NewEncoder(f).Encode(c.entries)
+	c.mu.Unlock()
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHashCache_RefreshPreservesOtherEntries guards against -refresh-cache
+// dropping cached hashes for files it didn't touch this run: refresh should
+// force recomputation for the paths actually hashed, not discard the rest of
+// the persistent, machine-wide cache.
+func TestHashCache_RefreshPreservesOtherEntries(t *testing.T) {
+	dir := t.TempDir()
+	untouched := filepath.Join(dir, "untouched.txt")
+	refreshed := filepath.Join(dir, "refreshed.txt")
+	if err := os.WriteFile(untouched, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(refreshed, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath := filepath.Join(dir, "cache.db")
+	cache, err := loadHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.hash(untouched, "md5"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.hash(refreshed, "md5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = loadHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.refresh = true
+	if _, err := cache.hash(refreshed, "md5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := loadHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs, err := filepath.Abs(untouched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.entries[abs]; !ok {
+		t.Errorf("entries = %v, want %q (untouched during refresh) to still be cached", reloaded.entries, abs)
+	}
+}
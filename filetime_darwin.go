@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileTimeOf returns the requested timestamp kind ("modify", "access",
+// "change", or "birth") for info. On Darwin all four are available from
+// the underlying syscall.Stat_t.
+func fileTimeOf(info os.FileInfo, kind string) (time.Time, error) {
+	if kind == "modify" {
+		return info.ModTime(), nil
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, fmt.Errorf("fileTimeOf: no syscall.Stat_t for %s", info.Name())
+	}
+
+	switch kind {
+	case "access":
+		return time.Unix(stat.Atimespec.Sec, stat.Atimespec.Nsec), nil
+	case "change":
+		return time.Unix(stat.Ctimespec.Sec, stat.Ctimespec.Nsec), nil
+	case "birth":
+		return time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec), nil
+	default:
+		return time.Time{}, fmt.Errorf("fileTimeOf: unknown kind %q", kind)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileTimeOf returns the requested timestamp kind ("modify", "access",
+// "change", or "birth") for info. Linux's stat(2) has no birth time field;
+// getting one requires statx(2) with STATX_BTIME, which isn't always
+// supported by the underlying filesystem (e.g. ext4 without the right mount
+// options), so "birth" reliably returns an error here instead.
+func fileTimeOf(info os.FileInfo, kind string) (time.Time, error) {
+	if kind == "modify" {
+		return info.ModTime(), nil
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, fmt.Errorf("fileTimeOf: no syscall.Stat_t for %s", info.Name())
+	}
+
+	switch kind {
+	case "access":
+		return time.Unix(stat.Atim.Sec, stat.Atim.Nsec), nil
+	case "change":
+		return time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec), nil
+	case "birth":
+		return time.Time{}, fmt.Errorf("fileTimeOf: birth time not available on linux for %s", info.Name())
+	default:
+		return time.Time{}, fmt.Errorf("fileTimeOf: unknown kind %q", kind)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileTimeOf returns the requested timestamp kind ("modify", "access",
+// "change", or "birth") for info. Windows has no ctime ("change") concept
+// distinct from creation/write times, so that kind returns an error; "birth"
+// maps to the file's CreationTime.
+func fileTimeOf(info os.FileInfo, kind string) (time.Time, error) {
+	if kind == "modify" {
+		return info.ModTime(), nil
+	}
+
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, fmt.Errorf("fileTimeOf: no Win32FileAttributeData for %s", info.Name())
+	}
+
+	switch kind {
+	case "access":
+		return time.Unix(0, data.LastAccessTime.Nanoseconds()), nil
+	case "birth":
+		return time.Unix(0, data.CreationTime.Nanoseconds()), nil
+	case "change":
+		return time.Time{}, fmt.Errorf("fileTimeOf: change time not available on windows for %s", info.Name())
+	default:
+		return time.Time{}, fmt.Errorf("fileTimeOf: unknown kind %q", kind)
+	}
+}
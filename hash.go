@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/blake2b"
+)
+
+// newHasher returns a fresh hash.Hash for the named algorithm. Supported
+// names: md5, sha1, sha256, sha512, blake2b, blake3, xxh64.
+func newHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "blake2b":
+		return blake2b.New256(nil)
+	case "blake3":
+		return blake3.New(), nil
+	case "xxh64":
+		return xxhash.New(), nil
+	default:
+		return nil, fmt.Errorf("newHasher: unknown algorithm %q", algo)
+	}
+}
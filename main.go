@@ -1,97 +1,106 @@
 package main
 
 import (
-	"crypto/md5"
 	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
-	"syscall"
 	"time"
 )
 
-// FileMD5 stores the path and its MD5 hash
-type FileMD5 struct {
+// FileHash stores the path, the algorithm used, and the resulting hash.
+type FileHash struct {
 	Path string
-	MD5  string
+	Algo string
+	Hash string
 }
 
-// getMD5 calculates the MD5 checksum of a file
-func getMD5(filePath string) (string, error) {
+// getHash calculates the checksum of a file using the named algorithm.
+func getHash(filePath, algo string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
+	h, err := newHasher(algo)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, file); err != nil {
 		return "", err
 	}
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// processFile processes a single file
-func processFile(srcPath, backupPath string, cMinutes int, conditionType string, resFile, errFile *os.File, wg *sync.WaitGroup, mu *sync.Mutex) {
-	defer wg.Done()
+// processFile processes a single file, reporting exactly one Event.
+func processFile(srcPath, backupPath string, cMinutes int, timeKind, algo string, cache *hashCache, reporter Reporter) {
+	fmt.Fprintln(os.Stderr, "Checking:", srcPath)
 
-	// Print the file being checked
-	mu.Lock()
-	fmt.Println("Checking:", srcPath)
-	mu.Unlock()
+	srcHash, err := cache.hash(srcPath, algo)
+	if err != nil {
+		reporter.Report(Event{Kind: "error", Path: srcPath, Err: err})
+		return
+	}
 
-	srcMD5, err := getMD5(srcPath)
+	backupHash, err := cache.hash(backupPath, algo)
 	if err != nil {
-		mu.Lock()
-		_, _ = errFile.WriteString(fmt.Sprintf("Error calculating MD5 for source file: %s\n", srcPath))
-		mu.Unlock()
+		reporter.Report(Event{Kind: "missing", Path: srcPath, BackupPath: backupPath, SrcHash: srcHash})
+		return
+	}
+	if srcHash != backupHash {
+		reporter.Report(Event{Kind: "mismatch", Path: srcPath, BackupPath: backupPath, SrcHash: srcHash, BackupHash: backupHash})
 		return
 	}
 
-	backupMD5, err := getMD5(backupPath)
+	info, err := os.Stat(srcPath)
 	if err != nil {
-		mu.Lock()
-		_, _ = errFile.WriteString(fmt.Sprintf("File missing in backup: %s\n", backupPath))
-		mu.Unlock()
-	} else if srcMD5 != backupMD5 {
-		mu.Lock()
-		_, _ = errFile.WriteString(fmt.Sprintf("MD5 mismatch: %s\n", backupPath))
-		mu.Unlock()
-	} else {
-		info, err := os.Stat(srcPath)
-		if err != nil {
-			mu.Lock()
-			_, _ = errFile.WriteString(fmt.Sprintf("Error getting file info: %s\n", srcPath))
-			mu.Unlock()
-			return
-		}
+		reporter.Report(Event{Kind: "error", Path: srcPath, Err: err})
+		return
+	}
 
-		var fileTime time.Time
-		if conditionType == "access" {
-			tmpStat := info.Sys().(*syscall.Stat_t)
-			//macOS
-			fileTime = time.Unix(tmpStat.Atimespec.Sec, tmpStat.Atimespec.Nsec)
-			//Linux
-			// fileTime = time.Unix(tmpStat.Atim.Sec, tmpStat.Atim.Nsec)
-		} else {
-			fileTime = info.ModTime()
-		}
+	fileTime, err := fileTimeOf(info, timeKind)
+	if err != nil {
+		reporter.Report(Event{Kind: "error", Path: srcPath, Err: err})
+		return
+	}
 
-		if time.Since(fileTime).Minutes() > float64(cMinutes) {
-			mu.Lock()
-			_, _ = resFile.WriteString(fmt.Sprintf("%s\n", srcPath))
-			mu.Unlock()
-		}
+	if time.Since(fileTime).Minutes() > float64(cMinutes) {
+		reporter.Report(Event{Kind: "stale", Path: srcPath, BackupPath: backupPath, SrcHash: srcHash, Size: info.Size(), ModTime: fileTime})
+		return
 	}
+
+	reporter.Report(Event{Kind: "ok", Path: srcPath, BackupPath: backupPath, SrcHash: srcHash, Size: info.Size(), ModTime: fileTime})
 }
 
-// compareDirs compares files in source and backup directories
-func compareDirs(srcDir, backupDir string, cMinutes int, conditionType string, resFile, errFile *os.File) error {
+// filePair is one source/backup path pair queued for processFile.
+type filePair struct {
+	src    string
+	backup string
+}
+
+// compareDirs compares files in source and backup directories using a fixed
+// pool of jobs workers, so memory and open-file-descriptor use stay bounded
+// regardless of tree size.
+func compareDirs(srcDir, backupDir string, cMinutes int, timeKind, algo string, jobs int, cache *hashCache, reporter Reporter) error {
+	pairs := make(chan filePair, jobs)
+
 	var wg sync.WaitGroup
-	var mu sync.Mutex
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pair := range pairs {
+				processFile(pair.src, pair.backup, cMinutes, timeKind, algo, cache, reporter)
+			}
+		}()
+	}
 
 	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -107,47 +116,356 @@ func compareDirs(srcDir, backupDir string, cMinutes int, conditionType string, r
 			return err
 		}
 
-		backupPath := filepath.Join(backupDir, relPath)
-		wg.Add(1)
-		go processFile(path, backupPath, cMinutes, conditionType, resFile, errFile, &wg, &mu)
+		pairs <- filePair{src: path, backup: filepath.Join(backupDir, relPath)}
 		return nil
 	})
 
+	close(pairs)
 	wg.Wait()
 	return err
 }
 
+// fileInfo is a single entry emitted by a walker goroutine in compareDirsN.
+type fileInfo struct {
+	Name string // path relative to the tree root
+	Size int64
+	Hash string
+}
+
+// walkEntry is a file discovered by walkTree, queued for hashing once the
+// full tree listing has been sorted.
+type walkEntry struct {
+	relPath string // slash-joined, relative to the tree root
+	absPath string
+	size    int64
+}
+
+// walkTree walks root and sends one fileInfo per regular file on out, in
+// ascending order by slash-joined relative path. filepath.Walk only sorts
+// each directory's entries by basename, so a raw DFS can emit e.g.
+// "foo/child.txt" before "foo.txt" even though "foo.txt" < "foo/child.txt"
+// as full path strings; compareDirsN's merge requires the latter, global
+// order, so entries are buffered and sorted before anything is sent. It
+// stops early if abort is closed, and always closes out before returning.
+func walkTree(root, algo string, cache *hashCache, out chan<- fileInfo, abort <-chan struct{}) error {
+	defer close(out)
+
+	var entries []walkEntry
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, walkEntry{relPath: filepath.ToSlash(relPath), absPath: path, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].relPath < entries[j].relPath })
+
+	for _, e := range entries {
+		select {
+		case <-abort:
+			return nil
+		default:
+		}
+
+		sum, err := cache.hash(e.absPath, algo)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case out <- fileInfo{Name: e.relPath, Size: e.size, Hash: sum}:
+		case <-abort:
+			return nil
+		}
+	}
+	return nil
+}
+
+// compareDirsN audits an arbitrary number of directories against each other,
+// reporting any file present in some trees but not others, or present in all
+// but with mismatched size/hash. It streams each tree through its own walker
+// goroutine instead of spawning one goroutine per file, so memory use stays
+// bounded regardless of tree size.
+func compareDirsN(algo string, cache *hashCache, reporter Reporter, dirs ...string) error {
+	if len(dirs) < 2 {
+		return fmt.Errorf("compareDirsN: need at least 2 directories, got %d", len(dirs))
+	}
+
+	abort := make(chan struct{})
+	chans := make([]chan fileInfo, len(dirs))
+	walkErrs := make([]error, len(dirs))
+
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		chans[i] = make(chan fileInfo, 64)
+		wg.Add(1)
+		go func(i int, dir string) {
+			defer wg.Done()
+			walkErrs[i] = walkTree(dir, algo, cache, chans[i], abort)
+		}(i, dir)
+	}
+
+	abortOnce := func() {
+		select {
+		case <-abort:
+		default:
+			close(abort)
+		}
+	}
+	defer abortOnce()
+
+	heads := make([]fileInfo, len(dirs))
+	have := make([]bool, len(dirs))
+	for i := range dirs {
+		entry, ok := <-chans[i]
+		heads[i] = entry
+		have[i] = ok
+	}
+
+	for {
+		anyHave := false
+		minName := ""
+		for i := range dirs {
+			if !have[i] {
+				continue
+			}
+			anyHave = true
+			if minName == "" || heads[i].Name < minName {
+				minName = heads[i].Name
+			}
+		}
+		if !anyHave {
+			break
+		}
+
+		var ref fileInfo
+		refSet := false
+		mismatch := false
+		allPresent := true
+		for i, dir := range dirs {
+			if !have[i] || heads[i].Name != minName {
+				reporter.Report(Event{Kind: "missing", Path: minName, BackupPath: dir})
+				allPresent = false
+				continue
+			}
+			if !refSet {
+				ref = heads[i]
+				refSet = true
+			} else if heads[i].Size != ref.Size || heads[i].Hash != ref.Hash {
+				mismatch = true
+			}
+		}
+		if mismatch {
+			reporter.Report(Event{Kind: "mismatch", Path: minName, Size: ref.Size, SrcHash: ref.Hash})
+			abortOnce()
+			break
+		}
+		if allPresent {
+			reporter.Report(Event{Kind: "ok", Path: minName, Size: ref.Size, SrcHash: ref.Hash})
+		}
+
+		for i := range dirs {
+			if have[i] && heads[i].Name == minName {
+				entry, ok := <-chans[i]
+				heads[i] = entry
+				have[i] = ok
+			}
+		}
+	}
+
+	abortOnce()
+	wg.Wait()
+
+	for i := range dirs {
+		if walkErrs[i] != nil {
+			return fmt.Errorf("walking %s: %w", dirs[i], walkErrs[i])
+		}
+	}
+	return nil
+}
+
 func main() {
 	srcDir := flag.String("src", "", "Source directory")
 	backupDir := flag.String("backup", "", "Backup directory")
 	cMinutes := flag.Int("minutes", 0, "Time in minutes")
-	conditionType := flag.String("type", "modify", "Condition type (modify/access)")
+	timeKind := flag.String("time", "modify", "Timestamp to check: modify, access, change, or birth")
+	dirsFlag := flag.String("dirs", "", "Comma-separated list of 2+ directories to audit as equal replicas (N-way mode, ignores -backup/-minutes/-time)")
+	manifestWrite := flag.String("manifest-write", "", "Write a checksum manifest of -src to this file and exit")
+	manifestCheck := flag.String("manifest-check", "", "Re-hash -src against this manifest and exit")
+	manifestFormat := flag.String("manifest-format", "text", "Manifest format: text (md5sum-compatible) or json")
+	algo := flag.String("algo", "md5", "Hash algorithm: md5, sha1, sha256, sha512, blake2b, blake3, or xxh64")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of concurrent hashing workers (compareDirs mode)")
+	noCache := flag.Bool("no-cache", false, "Disable the on-disk hash cache")
+	refreshCache := flag.Bool("refresh-cache", false, "Ignore any existing hash cache entries and recompute everything")
+	output := flag.String("output", "text", "Report format: text (res.txt/error.txt) or jsonl")
+	outputFile := flag.String("output-file", "", "Write jsonl reports to this file instead of stdout (ignored for -output=text)")
+	summary := flag.Bool("summary", false, "Print an aggregate count of each event kind to stderr at the end")
 
 	flag.Parse()
 
-	if *srcDir == "" || *backupDir == "" || *cMinutes == 0 {
-		fmt.Println("Usage: go run main.go -src <source directory> -backup <backup directory> -minutes <time in minutes> -type <condition type>")
+	if _, err := newHasher(*algo); err != nil {
+		fmt.Printf("Invalid -algo: %v\n", err)
 		return
 	}
 
-	resFile, err := os.OpenFile("res.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("Error opening result file: %v\n", err)
+	if *manifestFormat != "text" && *manifestFormat != "json" {
+		fmt.Printf("Invalid -manifest-format %q: must be text or json\n", *manifestFormat)
 		return
 	}
-	defer resFile.Close()
 
-	errFile, err := os.OpenFile("error.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("Error opening error file: %v\n", err)
+	if *jobs < 1 {
+		fmt.Printf("Invalid -jobs %d: must be at least 1\n", *jobs)
 		return
 	}
-	defer errFile.Close()
 
-	err = compareDirs(*srcDir, *backupDir, *cMinutes, *conditionType, resFile, errFile)
-	if err != nil {
+	var base Reporter
+	switch *output {
+	case "text":
+		resFile, err := os.OpenFile("res.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Printf("Error opening result file: %v\n", err)
+			return
+		}
+		defer resFile.Close()
+
+		errFile, err := os.OpenFile("error.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Printf("Error opening error file: %v\n", err)
+			return
+		}
+		defer errFile.Close()
+
+		base = &TextReporter{Res: resFile, Err: errFile}
+	case "jsonl":
+		w := io.Writer(os.Stdout)
+		if *outputFile != "" {
+			f, err := os.Create(*outputFile)
+			if err != nil {
+				fmt.Printf("Error creating output file: %v\n", err)
+				return
+			}
+			defer f.Close()
+			w = f
+		}
+		base = &JSONLReporter{W: w}
+	default:
+		fmt.Printf("Invalid -output %q: must be text or jsonl\n", *output)
+		return
+	}
+
+	var counting *CountingReporter
+	reporter := base
+	if *summary {
+		counting = NewCountingReporter(base)
+		reporter = counting
+	}
+	defer func() {
+		if counting != nil {
+			fmt.Fprintln(os.Stderr, counting.Summary())
+		}
+	}()
+
+	var cache *hashCache
+	if !*noCache {
+		cachePath, err := defaultCachePath()
+		if err != nil {
+			fmt.Printf("Error resolving cache path: %v\n", err)
+			return
+		}
+		cache, err = loadHashCache(cachePath)
+		if err != nil {
+			fmt.Printf("Error loading hash cache: %v\n", err)
+			return
+		}
+		cache.refresh = *refreshCache
+		defer func() {
+			if err := cache.save(); err != nil {
+				fmt.Printf("Error saving hash cache: %v\n", err)
+			}
+		}()
+	}
+
+	if *manifestWrite != "" {
+		if *srcDir == "" {
+			fmt.Println("Usage: go run main.go -src <source directory> -manifest-write <file> [-manifest-format=text|json]")
+			return
+		}
+		out, err := os.Create(*manifestWrite)
+		if err != nil {
+			fmt.Printf("Error creating manifest file: %v\n", err)
+			return
+		}
+		defer out.Close()
+		if err := writeManifest(*srcDir, out, *manifestFormat, *algo, cache); err != nil {
+			fmt.Printf("Error writing manifest: %v\n", err)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "Manifest written to", *manifestWrite)
+		return
+	}
+
+	if *manifestCheck != "" {
+		if *srcDir == "" {
+			fmt.Println("Usage: go run main.go -src <source directory> -manifest-check <file> [-manifest-format=text|json]")
+			return
+		}
+		manifest, err := os.Open(*manifestCheck)
+		if err != nil {
+			fmt.Printf("Error opening manifest file: %v\n", err)
+			return
+		}
+		defer manifest.Close()
+
+		if err := checkManifest(*srcDir, manifest, *manifestFormat, *algo, cache, reporter); err != nil {
+			fmt.Printf("Error checking manifest: %v\n", err)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "Manifest check complete.")
+		return
+	}
+
+	if *dirsFlag != "" {
+		dirs := strings.Split(*dirsFlag, ",")
+		if len(dirs) < 2 {
+			fmt.Println("Usage: go run main.go -dirs <dir1>,<dir2>[,<dir3>...]")
+			return
+		}
+		if err := compareDirsN(*algo, cache, reporter, dirs...); err != nil {
+			fmt.Printf("Error comparing directories: %v\n", err)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "N-way comparison complete.")
+		return
+	}
+
+	if *srcDir == "" || *backupDir == "" || *cMinutes == 0 {
+		fmt.Println("Usage: go run main.go -src <source directory> -backup <backup directory> -minutes <time in minutes> -time <modify|access|change|birth>")
+		return
+	}
+
+	switch *timeKind {
+	case "modify", "access", "change", "birth":
+	default:
+		fmt.Printf("Invalid -time %q: must be modify, access, change, or birth\n", *timeKind)
+		return
+	}
+
+	if err := compareDirs(*srcDir, *backupDir, *cMinutes, *timeKind, *algo, *jobs, cache, reporter); err != nil {
 		fmt.Printf("Error comparing directories: %v\n", err)
 	} else {
-		fmt.Println("Comparison complete. Check res.txt and error.txt for details.")
+		fmt.Fprintln(os.Stderr, "Comparison complete.")
 	}
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordingReporter collects every Event it's given, in order, for assertions.
+type recordingReporter struct {
+	events []Event
+}
+
+func (r *recordingReporter) Report(e Event) {
+	r.events = append(r.events, e)
+}
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestCompareDirsN_NamePrefixCollisions guards against compareDirsN's merge
+// relying on walkTree emitting names in a global sort order: filepath.Walk
+// only sorts by basename within a directory, so a directory name that's a
+// string-prefix of a sibling file name (".git"/".gitignore", "foo"/"foo.txt")
+// used to come out in DFS order instead, producing false "missing" reports.
+func TestCompareDirsN_NamePrefixCollisions(t *testing.T) {
+	tests := []struct {
+		name        string
+		filesA      map[string]string
+		filesB      map[string]string
+		wantMissing []string
+	}{
+		{
+			name: "directory name is a prefix of sibling file names",
+			filesA: map[string]string{
+				"foo/child.txt":   "child",
+				"foo-sibling.txt": "sibling",
+				"foo.txt":         "dot",
+			},
+			filesB: map[string]string{
+				"foo-sibling.txt": "sibling",
+				"foo.txt":         "dot",
+			},
+			wantMissing: []string{"foo/child.txt"},
+		},
+		{
+			name: "dotfile name is a prefix of a sibling dotfile",
+			filesA: map[string]string{
+				".git":       "a",
+				".gitignore": "b",
+			},
+			filesB: map[string]string{
+				".git":       "a",
+				".gitignore": "b",
+			},
+			wantMissing: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirA := t.TempDir()
+			dirB := t.TempDir()
+			writeTree(t, dirA, tt.filesA)
+			writeTree(t, dirB, tt.filesB)
+
+			cache := &hashCache{entries: make(map[string]cacheEntry)}
+			rep := &recordingReporter{}
+			if err := compareDirsN("md5", cache, rep, dirA, dirB); err != nil {
+				t.Fatalf("compareDirsN: %v", err)
+			}
+
+			var missing []string
+			for _, e := range rep.events {
+				if e.Kind == "missing" {
+					missing = append(missing, e.Path)
+				}
+			}
+			if len(missing) != len(tt.wantMissing) {
+				t.Fatalf("missing events = %v, want %v", missing, tt.wantMissing)
+			}
+			for i, name := range tt.wantMissing {
+				if missing[i] != name {
+					t.Errorf("missing[%d] = %q, want %q", i, missing[i], name)
+				}
+			}
+		})
+	}
+}
+
+// TestCompareDirsN_OkEvents guards against two failure modes for the "ok"
+// Event: not being reported at all when every dir agrees (leaving -summary
+// unable to distinguish "0 audited" from "N audited, all clean"), and being
+// reported for a name that's actually missing from some of the dirs.
+func TestCompareDirsN_OkEvents(t *testing.T) {
+	dirA := t.TempDir()
+	dirB := t.TempDir()
+	dirC := t.TempDir()
+	writeTree(t, dirA, map[string]string{"both.txt": "same", "onlya.txt": "only"})
+	writeTree(t, dirB, map[string]string{"both.txt": "same"})
+	writeTree(t, dirC, map[string]string{"both.txt": "same"})
+
+	cache := &hashCache{entries: make(map[string]cacheEntry)}
+	rep := &recordingReporter{}
+	if err := compareDirsN("md5", cache, rep, dirA, dirB, dirC); err != nil {
+		t.Fatalf("compareDirsN: %v", err)
+	}
+
+	var ok, missing []string
+	for _, e := range rep.events {
+		switch e.Kind {
+		case "ok":
+			ok = append(ok, e.Path)
+		case "missing":
+			missing = append(missing, e.Path)
+		}
+	}
+
+	if want := []string{"both.txt"}; len(ok) != len(want) || ok[0] != want[0] {
+		t.Errorf("ok events = %v, want %v", ok, want)
+	}
+	if len(missing) != 2 {
+		t.Errorf("missing events = %v, want 2 entries for onlya.txt", missing)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCheckManifest_BinaryModeAsterisk guards against the request's own
+// interoperability claim: GNU coreutils' md5sum -b/--binary (the default on
+// Windows-style invocations) prefixes the filename field with "*", which
+// used to make checkManifest look up "*file" instead of "file" and report
+// every entry as missing.
+func TestCheckManifest_BinaryModeAsterisk(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "text mode", line: "%s  target.txt\n"},
+		{name: "binary mode asterisk", line: "%s *target.txt\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := t.TempDir()
+			target := filepath.Join(srcDir, "target.txt")
+			if err := os.WriteFile(target, []byte("hello"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cache := &hashCache{entries: make(map[string]cacheEntry)}
+			sum, err := cache.hash(target, "md5")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			manifestPath := filepath.Join(t.TempDir(), "manifest.txt")
+			if err := os.WriteFile(manifestPath, []byte(fmt.Sprintf(tt.line, sum)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			manifest, err := os.Open(manifestPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer manifest.Close()
+
+			rep := &recordingReporter{}
+			if err := checkManifest(srcDir, manifest, "text", "md5", cache, rep); err != nil {
+				t.Fatalf("checkManifest: %v", err)
+			}
+
+			if len(rep.events) != 1 || rep.events[0].Kind != "ok" || rep.events[0].Path != "target.txt" {
+				t.Fatalf("events = %+v, want a single ok event for target.txt", rep.events)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event describes one outcome of a comparison, manifest check, or walk: a
+// mismatch, a file missing from a tree, a file stale past -minutes, an
+// error, or a clean "ok" result.
+type Event struct {
+	Kind       string // "mismatch", "missing", "stale", "error", or "ok"
+	Path       string
+	BackupPath string
+	SrcHash    string
+	BackupHash string
+	Size       int64
+	ModTime    time.Time
+	Err        error
+}
+
+// Reporter receives Events as they're produced. Implementations must be
+// safe for concurrent use, since compareDirs reports from multiple workers.
+type Reporter interface {
+	Report(Event)
+}
+
+// TextReporter reproduces the tool's original res.txt/error.txt behavior:
+// stale files go to Res, everything else noteworthy goes to Err.
+type TextReporter struct {
+	Res, Err *os.File
+	mu       sync.Mutex
+}
+
+func (r *TextReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch e.Kind {
+	case "stale":
+		_, _ = fmt.Fprintf(r.Res, "%s\n", e.Path)
+	case "missing":
+		_, _ = fmt.Fprintf(r.Err, "File missing in backup: %s\n", e.BackupPath)
+	case "mismatch":
+		_, _ = fmt.Fprintf(r.Err, "Hash mismatch: %s\n", e.BackupPath)
+	case "error":
+		_, _ = fmt.Fprintf(r.Err, "Error processing %s: %v\n", e.Path, e.Err)
+	}
+}
+
+// jsonEvent is Event's wire representation: Err becomes a plain string, and
+// zero ModTimes are omitted instead of printing as "0001-01-01...".
+type jsonEvent struct {
+	Kind       string     `json:"kind"`
+	Path       string     `json:"path,omitempty"`
+	BackupPath string     `json:"backup_path,omitempty"`
+	SrcHash    string     `json:"src_hash,omitempty"`
+	BackupHash string     `json:"backup_hash,omitempty"`
+	Size       int64      `json:"size,omitempty"`
+	ModTime    *time.Time `json:"mod_time,omitempty"`
+	Err        string     `json:"err,omitempty"`
+}
+
+// JSONLReporter writes one JSON object per Event, one per line, to W.
+type JSONLReporter struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+func (r *JSONLReporter) Report(e Event) {
+	je := jsonEvent{
+		Kind:       e.Kind,
+		Path:       e.Path,
+		BackupPath: e.BackupPath,
+		SrcHash:    e.SrcHash,
+		BackupHash: e.BackupHash,
+		Size:       e.Size,
+	}
+	if !e.ModTime.IsZero() {
+		je.ModTime = &e.ModTime
+	}
+	if e.Err != nil {
+		je.Err = e.Err.Error()
+	}
+
+	line, err := json.Marshal(je)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = r.W.Write(line)
+}
+
+// CountingReporter forwards every Event to Next (if set) while tallying
+// totals per Kind for a final summary.
+type CountingReporter struct {
+	Next Reporter
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewCountingReporter(next Reporter) *CountingReporter {
+	return &CountingReporter{Next: next, counts: make(map[string]int)}
+}
+
+func (r *CountingReporter) Report(e Event) {
+	r.mu.Lock()
+	r.counts[e.Kind]++
+	r.mu.Unlock()
+
+	if r.Next != nil {
+		r.Next.Report(e)
+	}
+}
+
+// Summary renders the running totals as a single human-readable line.
+func (r *CountingReporter) Summary() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return fmt.Sprintf("ok=%d mismatch=%d missing=%d stale=%d error=%d",
+		r.counts["ok"], r.counts["mismatch"], r.counts["missing"], r.counts["stale"], r.counts["error"])
+}